@@ -0,0 +1,138 @@
+package cli
+
+import (
+	"sync"
+
+	"github.com/elves/elvish/cli/clitypes"
+	"github.com/elves/elvish/cli/el"
+	"github.com/elves/elvish/cli/el/codearea"
+	"github.com/elves/elvish/cli/term"
+	"github.com/elves/elvish/edit/ui"
+)
+
+// App represents a CLI app. It hosts a root widget -- the codearea by
+// default, or the widget built by spec.Root from that same codearea if set
+// (e.g. a split container composing the codearea with other panes) --
+// optionally overlaid by a stack of addon widgets such as a completion
+// menu, a history search prompt, or a navigation pane. When the addon stack
+// is non-empty, the topmost addon is the active and focused widget;
+// otherwise the root widget is.
+type App struct {
+	cfg AppSpec
+
+	CodeArea codearea.Widget
+	Root     el.Widget
+
+	StateMutex sync.RWMutex
+	State      State
+
+	addonMutex sync.RWMutex
+	addons     clitypes.AddonStack
+
+	prompt, rprompt promptState
+	highlight       highlightState
+}
+
+// NewApp creates a new App from the given spec.
+func NewApp(spec AppSpec) *App {
+	hl := spec.Highlighter
+	if hl == nil {
+		hl = dummyHighlighter{}
+	}
+	prompt := spec.Prompt
+	if prompt == nil {
+		prompt = constPrompt{}
+	}
+	rprompt := spec.RPrompt
+	if rprompt == nil {
+		rprompt = constPrompt{}
+	}
+	app := &App{cfg: spec, State: spec.State}
+	app.cfg.Highlighter, app.cfg.Prompt, app.cfg.RPrompt = hl, prompt, rprompt
+	app.prompt.content = prompt.Get()
+	app.prompt.watch(prompt)
+	app.rprompt.content = rprompt.Get()
+	app.rprompt.watch(rprompt)
+	app.highlight.highlighter = hl
+	app.highlight.watch(hl)
+	app.CodeArea = codearea.NewWithState(codearea.Config{
+		OverlayHandler: spec.OverlayHandler,
+		Highlighter:    app.highlight.get,
+		Prompt:         app.prompt.get,
+		RPrompt:        app.rprompt.get,
+		Abbreviations:  spec.Abbreviations,
+		QuotePaste:     spec.QuotePaste,
+	}, spec.CodeAreaState)
+	if spec.Root != nil {
+		app.Root = spec.Root(app.CodeArea)
+	} else {
+		app.Root = app.CodeArea
+	}
+	app.addons.Base = app.Root
+	return app
+}
+
+// RefreshPrompt re-triggers both the prompt and the rprompt, cancelling
+// whichever computation each had in flight. It is typically called after
+// the codearea's content changes.
+func (app *App) RefreshPrompt(force bool) {
+	app.prompt.refresh(app.cfg.Prompt, force)
+	app.rprompt.refresh(app.cfg.RPrompt, force)
+}
+
+// PushAddon pushes w onto the addon stack, making it the active and
+// focused widget until it is popped.
+func (app *App) PushAddon(w el.Widget) {
+	app.addonMutex.Lock()
+	defer app.addonMutex.Unlock()
+	app.addons.Push(w)
+}
+
+// PopAddon pops the topmost addon off the stack, if any. If the popped
+// addon implements clitypes.Dismisser, its Dismiss method is called before
+// it is discarded.
+func (app *App) PopAddon() {
+	app.addonMutex.Lock()
+	defer app.addonMutex.Unlock()
+	app.addons.Pop()
+}
+
+// ActiveWidget returns the widget that is currently on top: the topmost
+// addon if the addon stack is non-empty, or the root widget otherwise.
+func (app *App) ActiveWidget() el.Widget {
+	app.addonMutex.RLock()
+	defer app.addonMutex.RUnlock()
+	return app.addons.Active()
+}
+
+// FocusedWidget returns the widget that currently receives terminal
+// events. It is always the same as ActiveWidget.
+func (app *App) FocusedWidget() el.Widget {
+	return app.ActiveWidget()
+}
+
+// Render renders the active widget onto a region of the given width and
+// height.
+func (app *App) Render(width, height int) *ui.Buffer {
+	return app.ActiveWidget().Render(width, height)
+}
+
+// Handle dispatches event to the focused widget, after giving default
+// keybindings (such as Ctrl-R for history search) a chance to handle it.
+// Events that change the codearea's content re-trigger the prompt,
+// cancelling whatever computation was still in flight for the previous one.
+// This is detected by comparing the codearea's CodeBuffer before and after,
+// rather than by checking whether the codearea was the focused widget
+// directly, since the codearea may be nested inside Root (e.g. a split
+// container) rather than being Root itself.
+func (app *App) Handle(event term.Event) bool {
+	if app.tryStartHistSearch(event) {
+		return true
+	}
+	before := app.CodeArea.CopyState().CodeBuffer
+	handled := app.FocusedWidget().Handle(event)
+	if handled && app.CodeArea.CopyState().CodeBuffer != before {
+		app.RefreshPrompt(false)
+	}
+	return handled
+}
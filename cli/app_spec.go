@@ -1,8 +1,11 @@
 package cli
 
 import (
+	"context"
+
 	"github.com/elves/elvish/cli/el"
 	"github.com/elves/elvish/cli/el/codearea"
+	"github.com/elves/elvish/cli/el/histsearch"
 	"github.com/elves/elvish/styled"
 )
 
@@ -22,71 +25,129 @@ type AppSpec struct {
 	Abbreviations  func(f func(abbr, full string))
 	QuotePaste     func() bool
 
+	// HistoryStore, if non-nil, enables the default Ctrl-R keybinding to
+	// push a history search addon onto the addon stack.
+	HistoryStore histsearch.HistoryWalker
+
+	// Root, if non-nil, is called with the App's own codearea widget (built
+	// from CodeAreaState) to produce the App's root widget -- for example a
+	// split container composing the codearea with a live output viewer. The
+	// codearea it was built from remains reachable via App.CodeArea
+	// regardless of what Root returns.
+	Root func(codearea.Widget) el.Widget
+
 	CodeAreaState codearea.State
 	State         State
 }
 
+// genKey is the context.Value key under which WithGen stores a generation
+// number.
+type genKey struct{}
+
+// WithGen returns a context carrying gen, the generation number of the
+// Prompt.Trigger or Highlighter.Get call it is passed to. Implementations
+// that deliver results asynchronously should read it back with
+// GenFromContext and tag their late updates with it, so that a consumer
+// which has since moved on to a fresher generation can recognize and drop
+// the update.
+func WithGen(ctx context.Context, gen int) context.Context {
+	return context.WithValue(ctx, genKey{}, gen)
+}
+
+// GenFromContext extracts the generation number embedded by WithGen, or 0
+// if ctx does not carry one.
+func GenFromContext(ctx context.Context) int {
+	if gen, ok := ctx.Value(genKey{}).(int); ok {
+		return gen
+	}
+	return 0
+}
+
 // Highlighter represents a code highlighter whose result can be delivered
 // asynchronously.
 type Highlighter interface {
-	// Get returns the highlighted code and any static errors.
-	Get(code string) (styled.Text, []error)
+	// Get returns the highlighted code and any static errors. The given
+	// context is cancelled once a fresher Get call supersedes this one;
+	// implementations doing background work (e.g. checking whether a
+	// command exists) should stop that work, and avoid sending on
+	// LateUpdates, once ctx is done.
+	Get(ctx context.Context, code string) (styled.Text, []error)
 	// LateUpdates returns a channel for delivering late updates.
-	LateUpdates() <-chan styled.Text
+	LateUpdates() <-chan HighlightUpdate
+}
+
+// HighlightUpdate is delivered on a Highlighter's LateUpdates channel.
+type HighlightUpdate struct {
+	// Gen is the generation embedded in the context passed to the Get call
+	// that produced this update (see WithGen).
+	Gen     int
+	Content styled.Text
+	Errors  []error
 }
 
 // A Highlighter implementation that always returns plain text.
 type dummyHighlighter struct{}
 
-func (dummyHighlighter) Get(code string) (styled.Text, []error) {
+func (dummyHighlighter) Get(ctx context.Context, code string) (styled.Text, []error) {
 	return styled.Plain(code), nil
 }
 
-func (dummyHighlighter) LateUpdates() <-chan styled.Text { return nil }
+func (dummyHighlighter) LateUpdates() <-chan HighlightUpdate { return nil }
 
 // A Highlighter implementation useful for testing.
 type testHighlighter struct {
-	get         func(code string) (styled.Text, []error)
-	lateUpdates chan styled.Text
+	get         func(ctx context.Context, code string) (styled.Text, []error)
+	lateUpdates chan HighlightUpdate
 }
 
-func (hl testHighlighter) Get(code string) (styled.Text, []error) {
-	return hl.get(code)
+func (hl testHighlighter) Get(ctx context.Context, code string) (styled.Text, []error) {
+	return hl.get(ctx, code)
 }
 
-func (hl testHighlighter) LateUpdates() <-chan styled.Text {
+func (hl testHighlighter) LateUpdates() <-chan HighlightUpdate {
 	return hl.lateUpdates
 }
 
 // Prompt represents a prompt whose result can be delivered asynchronously.
 type Prompt interface {
-	// Trigger requests a re-computation of the prompt. The force flag is set
-	// when triggered for the first time during a ReadCode session or after a
-	// SIGINT that resets the editor.
-	Trigger(force bool)
+	// Trigger requests a re-computation of the prompt. The force flag is
+	// set when triggered for the first time during a ReadCode session or
+	// after a SIGINT that resets the editor. The given context is
+	// cancelled once a fresher Trigger call supersedes this one;
+	// implementations should stop their computation and avoid sending on
+	// LateUpdates once ctx is done.
+	Trigger(ctx context.Context, force bool)
 	// Get returns the current prompt.
 	Get() styled.Text
-	// LastUpdates returns a channel for delivering late updates.
-	LateUpdates() <-chan styled.Text
+	// LateUpdates returns a channel for delivering late updates.
+	LateUpdates() <-chan PromptUpdate
+}
+
+// PromptUpdate is delivered on a Prompt's LateUpdates channel.
+type PromptUpdate struct {
+	// Gen is the generation embedded in the context passed to the Trigger
+	// call that produced this update (see WithGen).
+	Gen     int
+	Content styled.Text
 }
 
 // A Prompt implementation that always return the same styled.Text.
 type constPrompt struct{ t styled.Text }
 
-func (constPrompt) Trigger(force bool)              {}
-func (p constPrompt) Get() styled.Text              { return p.t }
-func (constPrompt) LateUpdates() <-chan styled.Text { return nil }
+func (constPrompt) Trigger(ctx context.Context, force bool) {}
+func (p constPrompt) Get() styled.Text                      { return p.t }
+func (constPrompt) LateUpdates() <-chan PromptUpdate        { return nil }
 
 // A Prompt implementation useful for testing.
 type testPrompt struct {
-	trigger     func(force bool)
+	trigger     func(ctx context.Context, force bool)
 	get         func() styled.Text
-	lateUpdates chan styled.Text
+	lateUpdates chan PromptUpdate
 }
 
-func (p testPrompt) Trigger(force bool) {
+func (p testPrompt) Trigger(ctx context.Context, force bool) {
 	if p.trigger != nil {
-		p.trigger(force)
+		p.trigger(ctx, force)
 	}
 }
 
@@ -97,6 +158,6 @@ func (p testPrompt) Get() styled.Text {
 	return nil
 }
 
-func (p testPrompt) LateUpdates() <-chan styled.Text {
+func (p testPrompt) LateUpdates() <-chan PromptUpdate {
 	return p.lateUpdates
-}
\ No newline at end of file
+}
@@ -0,0 +1,73 @@
+package cli
+
+import (
+	"testing"
+
+	"github.com/elves/elvish/cli/el"
+	"github.com/elves/elvish/cli/term"
+	"github.com/elves/elvish/edit/ui"
+)
+
+type stubAddon struct{ name string }
+
+func (w stubAddon) Render(width, height int) *ui.Buffer {
+	return &ui.Buffer{Width: width, Lines: []ui.Line{{ui.Cell{Text: w.name}}}}
+}
+
+func (w stubAddon) Handle(event term.Event) bool { return false }
+
+type dismissingAddon struct {
+	stubAddon
+	dismissed *int
+}
+
+func (w dismissingAddon) Dismiss() { *w.dismissed++ }
+
+func TestApp_PushPopAddon(t *testing.T) {
+	app := NewApp(AppSpec{})
+
+	if app.ActiveWidget() != el.Widget(app.CodeArea) {
+		t.Errorf("ActiveWidget with no addons should be the codearea")
+	}
+	if app.FocusedWidget() != app.ActiveWidget() {
+		t.Errorf("FocusedWidget should always equal ActiveWidget")
+	}
+
+	app.PushAddon(stubAddon{"addon1"})
+	app.PushAddon(stubAddon{"addon2"})
+	if got := app.ActiveWidget(); got != el.Widget(stubAddon{"addon2"}) {
+		t.Errorf("got active widget %v, want the most recently pushed addon", got)
+	}
+
+	app.PopAddon()
+	if got := app.ActiveWidget(); got != el.Widget(stubAddon{"addon1"}) {
+		t.Errorf("got active widget %v, want the remaining addon", got)
+	}
+
+	app.PopAddon()
+	if app.ActiveWidget() != el.Widget(app.CodeArea) {
+		t.Errorf("ActiveWidget after popping all addons should fall back to the codearea")
+	}
+
+	// Popping with an empty stack is a no-op.
+	app.PopAddon()
+	if app.ActiveWidget() != el.Widget(app.CodeArea) {
+		t.Errorf("Pop on an empty addon stack should not change ActiveWidget")
+	}
+}
+
+func TestApp_PopAddonCallsDismissOnce(t *testing.T) {
+	app := NewApp(AppSpec{})
+	dismissed := 0
+	app.PushAddon(dismissingAddon{stubAddon{"addon"}, &dismissed})
+
+	app.PopAddon()
+	if dismissed != 1 {
+		t.Errorf("got Dismiss called %d times, want 1", dismissed)
+	}
+
+	app.PopAddon()
+	if dismissed != 1 {
+		t.Errorf("got Dismiss called %d times after popping empty stack, want 1", dismissed)
+	}
+}
@@ -42,4 +42,61 @@ func (w widgetWithOverlayHandler) Render(width, height int) *ui.Buffer {
 
 func (w widgetWithOverlayHandler) Handle(event term.Event) bool {
 	return w.overlay.Handle(event) || w.base.Handle(event)
-}
\ No newline at end of file
+}
+
+// Dismisser is implemented by widgets that need to run cleanup logic when
+// they are popped off an addon stack.
+type Dismisser interface {
+	// Dismiss is called right before the widget is discarded.
+	Dismiss()
+}
+
+// AddonStack manages a stack of addon widgets layered on top of a base
+// widget. It is the compositor underlying App's addon stack: the topmost
+// addon, if any, is solely responsible for rendering and event handling;
+// otherwise the base widget is active. This supersedes AddOverlayHandler for
+// callers that want to layer full widgets (completion menus, history
+// search, navigation panes) rather than a bare Handler.
+type AddonStack struct {
+	Base Widget
+
+	addons []Widget
+}
+
+// Push pushes w onto the stack, making it the active widget.
+func (s *AddonStack) Push(w Widget) {
+	s.addons = append(s.addons, w)
+}
+
+// Pop pops the topmost addon off the stack, if any, calling its Dismiss
+// method first if it implements Dismisser.
+func (s *AddonStack) Pop() {
+	n := len(s.addons)
+	if n == 0 {
+		return
+	}
+	addon := s.addons[n-1]
+	s.addons = s.addons[:n-1]
+	if dismisser, ok := addon.(Dismisser); ok {
+		dismisser.Dismiss()
+	}
+}
+
+// Active returns the topmost addon if the stack is non-empty, or Base
+// otherwise.
+func (s *AddonStack) Active() Widget {
+	if n := len(s.addons); n > 0 {
+		return s.addons[n-1]
+	}
+	return s.Base
+}
+
+// Render renders the active widget.
+func (s *AddonStack) Render(width, height int) *ui.Buffer {
+	return s.Active().Render(width, height)
+}
+
+// Handle routes event to the active widget.
+func (s *AddonStack) Handle(event term.Event) bool {
+	return s.Active().Handle(event)
+}
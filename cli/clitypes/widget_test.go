@@ -0,0 +1,76 @@
+package clitypes
+
+import (
+	"testing"
+
+	"github.com/elves/elvish/cli/term"
+	"github.com/elves/elvish/edit/ui"
+)
+
+type stubWidget struct{ name string }
+
+func (w stubWidget) Render(width, height int) *ui.Buffer {
+	return &ui.Buffer{Width: width, Lines: []ui.Line{{ui.Cell{Text: w.name}}}}
+}
+
+func (w stubWidget) Handle(event term.Event) bool { return false }
+
+type dismissingWidget struct {
+	stubWidget
+	dismissed *int
+}
+
+func (w dismissingWidget) Dismiss() { *w.dismissed++ }
+
+func TestAddonStack_PushPop(t *testing.T) {
+	base := stubWidget{"base"}
+	s := AddonStack{Base: base}
+
+	if s.Active() != Widget(base) {
+		t.Errorf("Active with empty stack should be Base")
+	}
+
+	addon := stubWidget{"addon"}
+	s.Push(addon)
+	if s.Active() != Widget(addon) {
+		t.Errorf("Active after Push should be the pushed addon")
+	}
+
+	s.Pop()
+	if s.Active() != Widget(base) {
+		t.Errorf("Active after popping the only addon should be Base again")
+	}
+
+	// Popping an empty stack is a no-op.
+	s.Pop()
+	if s.Active() != Widget(base) {
+		t.Errorf("Pop on an empty stack should not panic or change Active")
+	}
+}
+
+func TestAddonStack_PopCallsDismissOnce(t *testing.T) {
+	dismissed := 0
+	s := AddonStack{Base: stubWidget{"base"}}
+	s.Push(dismissingWidget{stubWidget{"addon"}, &dismissed})
+
+	s.Pop()
+	if dismissed != 1 {
+		t.Errorf("got Dismiss called %d times, want 1", dismissed)
+	}
+
+	// Popping again (now empty) must not call Dismiss again.
+	s.Pop()
+	if dismissed != 1 {
+		t.Errorf("got Dismiss called %d times after popping empty stack, want 1", dismissed)
+	}
+}
+
+func TestAddonStack_RenderRoutesToActive(t *testing.T) {
+	s := AddonStack{Base: stubWidget{"base"}}
+	s.Push(stubWidget{"addon"})
+
+	buf := s.Render(10, 1)
+	if len(buf.Lines) != 1 || buf.Lines[0][0].Text != "addon" {
+		t.Errorf("Render did not route to the topmost addon")
+	}
+}
@@ -0,0 +1,345 @@
+// Package complete implements a menu-driven completion widget.
+package complete
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/elves/elvish/cli/el"
+	"github.com/elves/elvish/cli/el/codearea"
+	"github.com/elves/elvish/cli/el/layout"
+	"github.com/elves/elvish/cli/term"
+	"github.com/elves/elvish/edit/ui"
+	"github.com/elves/elvish/styled"
+)
+
+// Completer produces completion candidates for the given code and dot
+// position.
+type Completer interface {
+	Complete(code string, dot int) (*Result, error)
+}
+
+// Result is the result of a completion.
+type Result struct {
+	// Start and End delimit the range in the code to be replaced by the
+	// selected candidate.
+	Start, End int
+	// Candidates is the full, flat list of candidates.
+	Candidates []Candidate
+	// Segments, if non-empty, groups Candidates hierarchically by the
+	// segment being completed (e.g. successive path components), enabling
+	// a menu that descends into sub-candidates when a segment is selected.
+	Segments []Segment
+}
+
+// Candidate is a single completion candidate.
+type Candidate struct {
+	// Text is spliced into the code on selection.
+	Text string
+	// Display is shown in the menu; defaults to Text if empty.
+	Display string
+	// Style to render Display with.
+	Style styled.Style
+}
+
+// Segment groups a set of Candidates that complete the same position, and
+// whose selection descends into a further level of candidates.
+type Segment struct {
+	Display    string
+	Candidates []Candidate
+}
+
+// Widget supports menu-driven completion. It implements the el.Widget
+// interface.
+type Widget interface {
+	el.Widget
+	// CopyState returns a copy of the state.
+	CopyState() State
+}
+
+// Config keeps the configuration for Widget.
+type Config struct {
+	// Completer produces the candidates to show.
+	Completer Completer
+	// CodeArea is the widget whose CodeBuffer gets the selected candidate
+	// spliced in.
+	CodeArea codearea.Widget
+	// ShowHelp, if set, drives an inline help line shown under the grid
+	// for the currently selected candidate.
+	ShowHelp func(Candidate) styled.Text
+	// Close is called after a selection is committed or the menu is
+	// cancelled. Typically set to App.PopAddon.
+	Close func()
+}
+
+// State keeps the state of Widget.
+type State struct {
+	// Start, End delimit the range in the code being completed.
+	Start, End int
+	// Filter is the text typed since the menu (or the current segment)
+	// was entered, used to prefix-filter Candidates.
+	Filter string
+	// Candidates are the candidates at the current level, already
+	// filtered by Filter.
+	Candidates []Candidate
+	// Selected is the index of the selected candidate in Candidates, or -1
+	// if there are none.
+	Selected int
+	// origin[i] is the index of Candidates[i] in the current level's
+	// unfiltered candidate list, used to find its sub-level if any.
+	origin []int
+}
+
+type widget struct {
+	Config
+
+	StateMutex sync.RWMutex
+	State      State
+
+	// levels is a stack of candidate lists; selecting a segment pushes its
+	// sub-candidates onto the stack, Backspace at an empty filter pops it.
+	levels []level
+}
+
+// level is one level of the navigation stack: a set of candidates to
+// filter and show. subLevels maps the index of each candidate that stands
+// for a Segment to the candidates of the further level it leads to when
+// selected.
+type level struct {
+	candidates []Candidate
+	subLevels  map[int][]Candidate
+}
+
+// New creates a new completion widget by invoking cfg.Completer against the
+// current state of cfg.CodeArea.
+func New(cfg Config) (Widget, error) {
+	s := cfg.CodeArea.CopyState()
+	result, err := cfg.Completer.Complete(s.CodeBuffer.Content, s.CodeBuffer.Dot)
+	if err != nil {
+		return nil, err
+	}
+	w := &widget{Config: cfg}
+	w.State = State{
+		Start: result.Start, End: result.End,
+		Filter: s.CodeBuffer.Content[result.Start:result.End],
+	}
+	w.levels = []level{segmentsOrCandidates(result)}
+	w.refilter()
+	return w, nil
+}
+
+func segmentsOrCandidates(result *Result) level {
+	if len(result.Segments) == 0 {
+		return level{candidates: result.Candidates}
+	}
+	lvl := level{
+		candidates: make([]Candidate, len(result.Segments)),
+		subLevels:  make(map[int][]Candidate, len(result.Segments)),
+	}
+	for i, seg := range result.Segments {
+		lvl.candidates[i] = Candidate{Display: seg.Display}
+		lvl.subLevels[i] = seg.Candidates
+	}
+	return lvl
+}
+
+func (w *widget) Render(width, height int) *ui.Buffer {
+	w.StateMutex.RLock()
+	state := w.State
+	w.StateMutex.RUnlock()
+
+	bb := ui.NewBufferBuilder(width)
+	layout.WriteListing(bb, " COMPLETING ", state.Filter)
+	writeGrid(bb, width, state.Candidates, state.Selected)
+	if w.ShowHelp != nil && state.Selected >= 0 && state.Selected < len(state.Candidates) {
+		bb.Newline().WriteStyled(w.ShowHelp(state.Candidates[state.Selected]))
+	}
+	b := bb.Buffer()
+	if height > 0 && len(b.Lines) > height {
+		b.Lines = b.Lines[:height]
+	}
+	return b
+}
+
+// writeGrid writes candidates to bb as a row-major grid of equal-width
+// columns, wrapping once a row would exceed width. The candidate at index
+// selected, if any, is rendered in inverse style.
+func writeGrid(bb *ui.BufferBuilder, width int, candidates []Candidate, selected int) {
+	if len(candidates) == 0 {
+		return
+	}
+	colWidth := 0
+	for _, c := range candidates {
+		if w := len(candidateText(c)); w > colWidth {
+			colWidth = w
+		}
+	}
+	colWidth += 2 // at least one column's worth of padding
+	cols := width / colWidth
+	if cols < 1 {
+		cols = 1
+	}
+	for i, c := range candidates {
+		if i%cols == 0 {
+			bb.Newline()
+		}
+		cell := padRight(candidateText(c), colWidth)
+		if i == selected {
+			bb.WriteStyled(styled.MakeText(cell, "inverse"))
+		} else {
+			bb.WritePlain(cell)
+		}
+	}
+}
+
+func candidateText(c Candidate) string {
+	if c.Display != "" {
+		return c.Display
+	}
+	return c.Text
+}
+
+func padRight(s string, width int) string {
+	if len(s) >= width {
+		return s
+	}
+	return s + strings.Repeat(" ", width-len(s))
+}
+
+func (w *widget) Handle(event term.Event) bool {
+	key, ok := event.(term.KeyEvent)
+	if !ok {
+		return false
+	}
+	switch ui.Key(key) {
+	case ui.K(ui.Tab), ui.K(ui.Down):
+		w.move(1)
+		return true
+	case ui.K(ui.Up):
+		w.move(-1)
+		return true
+	case ui.K(ui.Enter):
+		w.accept()
+		return true
+	case ui.K(ui.Escape):
+		w.cancel()
+		return true
+	case ui.K(ui.Backspace):
+		w.StateMutex.Lock()
+		empty := len(w.State.Filter) == 0
+		if !empty {
+			w.State.Filter = w.State.Filter[:len(w.State.Filter)-1]
+		}
+		w.StateMutex.Unlock()
+		if empty && len(w.levels) > 1 {
+			w.levels = w.levels[:len(w.levels)-1]
+		}
+		w.refilter()
+		return true
+	default:
+		if key.Mod != 0 || key.Rune < 0 || !isGraphic(key.Rune) {
+			return false
+		}
+		w.StateMutex.Lock()
+		w.State.Filter += string(key.Rune)
+		w.StateMutex.Unlock()
+		w.refilter()
+		return true
+	}
+}
+
+func isGraphic(r rune) bool { return r >= 0x20 }
+
+func (w *widget) move(delta int) {
+	w.StateMutex.Lock()
+	defer w.StateMutex.Unlock()
+	n := len(w.State.Candidates)
+	if n == 0 {
+		return
+	}
+	w.State.Selected = ((w.State.Selected+delta)%n + n) % n
+}
+
+// refilter recomputes State.Candidates by prefix-filtering the current
+// level against State.Filter.
+func (w *widget) refilter() {
+	w.StateMutex.Lock()
+	defer w.StateMutex.Unlock()
+	lvl := w.levels[len(w.levels)-1]
+	filter := strings.ToLower(w.State.Filter)
+	var candidates []Candidate
+	var origin []int
+	for i, c := range lvl.candidates {
+		name := c.Display
+		if name == "" {
+			name = c.Text
+		}
+		if strings.HasPrefix(strings.ToLower(name), filter) {
+			candidates = append(candidates, c)
+			origin = append(origin, i)
+		}
+	}
+	w.State.Candidates = candidates
+	w.State.origin = origin
+	if len(candidates) == 0 {
+		w.State.Selected = -1
+	} else {
+		w.State.Selected = 0
+	}
+}
+
+// enterSegment descends into the sub-level of the candidate at the given
+// original index, if it has one. It reports whether it did so.
+func (w *widget) enterSegment(origin int) bool {
+	w.StateMutex.RLock()
+	sub, ok := w.levels[len(w.levels)-1].subLevels[origin]
+	w.StateMutex.RUnlock()
+	if !ok {
+		return false
+	}
+	w.levels = append(w.levels, level{candidates: sub})
+	w.StateMutex.Lock()
+	w.State.Filter = ""
+	w.StateMutex.Unlock()
+	w.refilter()
+	return true
+}
+
+func (w *widget) accept() {
+	w.StateMutex.RLock()
+	state := w.State
+	w.StateMutex.RUnlock()
+	if state.Selected < 0 || state.Selected >= len(state.Candidates) {
+		w.cancel()
+		return
+	}
+	origin := state.origin[state.Selected]
+	if w.enterSegment(origin) {
+		return
+	}
+	selected := state.Candidates[state.Selected]
+	if w.CodeArea != nil {
+		w.CodeArea.MutateCodeAreaState(func(s *codearea.State) {
+			c := &s.CodeBuffer
+			*c = codearea.CodeBuffer{
+				Content: c.Content[:state.Start] + selected.Text + c.Content[state.End:],
+				Dot:     state.Start + len(selected.Text),
+			}
+		})
+	}
+	if w.Close != nil {
+		w.Close()
+	}
+}
+
+func (w *widget) cancel() {
+	if w.Close != nil {
+		w.Close()
+	}
+}
+
+func (w *widget) CopyState() State {
+	w.StateMutex.RLock()
+	defer w.StateMutex.RUnlock()
+	return w.State
+}
@@ -0,0 +1,91 @@
+package complete
+
+import (
+	"testing"
+
+	"github.com/elves/elvish/cli/el/codearea"
+	"github.com/elves/elvish/cli/term"
+	"github.com/elves/elvish/edit/ui"
+)
+
+type stubCompleter struct {
+	result *Result
+	err    error
+}
+
+func (c stubCompleter) Complete(code string, dot int) (*Result, error) {
+	return c.result, c.err
+}
+
+func key(k ui.Key) term.Event { return term.KeyEvent(k) }
+
+func TestWidget_FlatCandidates(t *testing.T) {
+	codeArea := codearea.NewWithState(codearea.Config{},
+		codearea.State{CodeBuffer: codearea.CodeBuffer{Content: "echo f", Dot: 6}})
+	w, err := New(Config{
+		Completer: stubCompleter{result: &Result{
+			Start: 5, End: 6,
+			Candidates: []Candidate{{Text: "foo"}, {Text: "foobar"}, {Text: "bar"}},
+		}},
+		CodeArea: codeArea,
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	w.Handle(key(ui.K('o')))
+	state := w.CopyState()
+	if len(state.Candidates) != 2 {
+		t.Fatalf("got %d candidates after filtering, want 2", len(state.Candidates))
+	}
+
+	w.Handle(key(ui.K(ui.Enter)))
+	got := codeArea.CopyState().CodeBuffer
+	if got.Content != "echo foo" || got.Dot != 8 {
+		t.Errorf("got code buffer %+v, want Content=echo foo Dot=8", got)
+	}
+}
+
+func TestWidget_RenderGrid(t *testing.T) {
+	codeArea := codearea.NewWithState(codearea.Config{}, codearea.State{})
+	w, err := New(Config{
+		Completer: stubCompleter{result: &Result{
+			Candidates: []Candidate{{Text: "a"}, {Text: "b"}, {Text: "c"}, {Text: "d"}},
+		}},
+		CodeArea: codeArea,
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	// Each candidate takes a 3-column cell ("a" + 2 padding), so a width of
+	// 6 fits exactly 2 candidates per row, wrapping the other 2 onto a
+	// second row.
+	buf := w.Render(6, 10)
+	// The mode line and (empty) filter share one line, followed by 2 grid
+	// rows.
+	if len(buf.Lines) != 3 {
+		t.Fatalf("got %d lines, want 3 (mode+filter line + 2 grid rows)", len(buf.Lines))
+	}
+}
+
+func TestWidget_Segments(t *testing.T) {
+	codeArea := codearea.NewWithState(codearea.Config{}, codearea.State{})
+	w, err := New(Config{
+		Completer: stubCompleter{result: &Result{
+			Segments: []Segment{
+				{Display: "usr", Candidates: []Candidate{{Text: "bin"}, {Text: "lib"}}},
+			},
+		}},
+		CodeArea: codeArea,
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	w.Handle(key(ui.K(ui.Enter)))
+	state := w.CopyState()
+	if len(state.Candidates) != 2 || state.Candidates[0].Text != "bin" {
+		t.Errorf("got candidates %+v, want descent into [bin lib]", state.Candidates)
+	}
+}
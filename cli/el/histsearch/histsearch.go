@@ -0,0 +1,219 @@
+// Package histsearch implements a widget for performing an incremental
+// history search, similar to Ctrl-R in readline-based shells.
+package histsearch
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/elves/elvish/cli/el"
+	"github.com/elves/elvish/cli/el/codearea"
+	"github.com/elves/elvish/cli/el/layout"
+	"github.com/elves/elvish/cli/term"
+	"github.com/elves/elvish/edit/ui"
+	"github.com/elves/elvish/styled"
+)
+
+// HistoryWalker is the interface for walking through history entries by
+// sequence number, as implemented by store.DBStore.
+type HistoryWalker interface {
+	// PrevCmd returns the sequence number and content of the latest
+	// history entry with a sequence number less than upper.
+	PrevCmd(upper int) (int, string, error)
+	// NextCmd returns the sequence number and content of the earliest
+	// history entry with a sequence number greater than lower.
+	NextCmd(lower int) (int, string, error)
+}
+
+// Widget supports incremental history search. It implements the el.Widget
+// interface.
+type Widget interface {
+	el.Widget
+	// CopyState returns a copy of the state.
+	CopyState() State
+}
+
+// Config keeps the configuration for Widget.
+type Config struct {
+	// Store to search against.
+	Store HistoryWalker
+	// CodeArea is the widget whose CodeBuffer gets the matched command
+	// spliced in at the dot when a match is committed.
+	CodeArea codearea.Widget
+	// Prompt, if set, overrides the plain mode-name string passed to
+	// layout.WriteListing for the mode line.
+	Prompt func() string
+	// Filter reports whether entry is a match for query. If unset, a
+	// case-insensitive substring match is used.
+	Filter func(query, entry string) bool
+	// Close is called after the search is finished, either by committing a
+	// match or by being cancelled. Typically set to App.PopAddon.
+	Close func()
+}
+
+// State keeps the state of Widget.
+type State struct {
+	Query string
+	Seq   int
+	Match string
+}
+
+type widget struct {
+	Config
+
+	StateMutex sync.RWMutex
+	State      State
+}
+
+// New creates a new history search widget.
+func New(cfg Config) Widget {
+	if cfg.Filter == nil {
+		cfg.Filter = func(query, entry string) bool {
+			return strings.Contains(
+				strings.ToLower(entry), strings.ToLower(query))
+		}
+	}
+	w := &widget{Config: cfg, State: State{Seq: -1}}
+	w.search(-1, w.prevCmd)
+	return w
+}
+
+func (w *widget) prompt() string {
+	if w.Prompt != nil {
+		return w.Prompt()
+	}
+	return " HISTORY "
+}
+
+func (w *widget) Render(width, height int) *ui.Buffer {
+	w.StateMutex.RLock()
+	state := w.State
+	w.StateMutex.RUnlock()
+
+	bb := ui.NewBufferBuilder(width)
+	layout.WriteListing(bb, w.prompt(), state.Query)
+	writeMatch(bb, state.Match, state.Query)
+	b := bb.Buffer()
+	if height > 0 && len(b.Lines) > height {
+		b.Lines = b.Lines[:height]
+	}
+	return b
+}
+
+// writeMatch writes match on its own line, with the first occurrence of
+// query, if any, highlighted in inverse style.
+func writeMatch(bb *ui.BufferBuilder, match, query string) {
+	bb.Newline()
+	if query != "" {
+		if i := strings.Index(strings.ToLower(match), strings.ToLower(query)); i >= 0 {
+			bb.WritePlain(match[:i])
+			bb.WriteStyled(styled.MakeText(match[i:i+len(query)], "inverse"))
+			bb.WritePlain(match[i+len(query):])
+			return
+		}
+	}
+	bb.WritePlain(match)
+}
+
+func (w *widget) Handle(event term.Event) bool {
+	key, ok := event.(term.KeyEvent)
+	if !ok {
+		return false
+	}
+	switch ui.Key(key) {
+	case ui.K(ui.Enter):
+		w.commit()
+		return true
+	case ui.K(ui.Escape):
+		w.cancel()
+		return true
+	case ui.K('R', ui.Ctrl):
+		w.StateMutex.RLock()
+		seq := w.State.Seq
+		w.StateMutex.RUnlock()
+		w.search(seq, w.prevCmd)
+		return true
+	case ui.K('S', ui.Ctrl):
+		w.StateMutex.RLock()
+		seq := w.State.Seq
+		w.StateMutex.RUnlock()
+		w.search(seq, w.nextCmd)
+		return true
+	case ui.K(ui.Backspace):
+		w.StateMutex.Lock()
+		q := w.State.Query
+		if len(q) > 0 {
+			w.State.Query = q[:len(q)-1]
+		}
+		w.StateMutex.Unlock()
+		w.search(-1, w.prevCmd)
+		return true
+	default:
+		if key.Mod != 0 || key.Rune < 0 {
+			return false
+		}
+		w.StateMutex.Lock()
+		w.State.Query += string(key.Rune)
+		w.StateMutex.Unlock()
+		w.search(-1, w.prevCmd)
+		return true
+	}
+}
+
+func (w *widget) prevCmd(seq int) (int, string, error) { return w.Store.PrevCmd(seq) }
+func (w *widget) nextCmd(seq int) (int, string, error) { return w.Store.NextCmd(seq) }
+
+// search walks the store starting from seq (or the newest entry if seq is
+// -1) using step, looking for the first entry matching the current query.
+func (w *widget) search(seq int, step func(int) (int, string, error)) {
+	w.StateMutex.RLock()
+	query := w.State.Query
+	if seq == -1 {
+		seq = mostRecentSeq
+	}
+	w.StateMutex.RUnlock()
+
+	for {
+		nextSeq, entry, err := step(seq)
+		if err != nil {
+			return
+		}
+		seq = nextSeq
+		if query == "" || w.Filter(query, entry) {
+			w.StateMutex.Lock()
+			w.State.Seq = seq
+			w.State.Match = entry
+			w.StateMutex.Unlock()
+			return
+		}
+	}
+}
+
+// mostRecentSeq is passed to PrevCmd to mean "start from the newest entry".
+const mostRecentSeq = 1<<31 - 1
+
+func (w *widget) commit() {
+	w.StateMutex.RLock()
+	match := w.State.Match
+	w.StateMutex.RUnlock()
+	if match != "" && w.CodeArea != nil {
+		w.CodeArea.MutateCodeAreaState(func(s *codearea.State) {
+			s.CodeBuffer.InsertAtDot(match)
+		})
+	}
+	if w.Close != nil {
+		w.Close()
+	}
+}
+
+func (w *widget) cancel() {
+	if w.Close != nil {
+		w.Close()
+	}
+}
+
+func (w *widget) CopyState() State {
+	w.StateMutex.RLock()
+	defer w.StateMutex.RUnlock()
+	return w.State
+}
@@ -0,0 +1,102 @@
+package histsearch
+
+import (
+	"testing"
+
+	"github.com/elves/elvish/cli/el/codearea"
+	"github.com/elves/elvish/cli/term"
+	"github.com/elves/elvish/edit/ui"
+	"github.com/elves/elvish/pkg/store"
+)
+
+func key(k ui.Key) term.Event { return term.KeyEvent(k) }
+
+func TestWidget(t *testing.T) {
+	st, cleanup := store.MustGetTempStore()
+	defer cleanup()
+	for _, cmd := range []string{"echo foo", "echo bar", "ls", "echo foobar"} {
+		st.AddCmd(cmd)
+	}
+
+	codeArea := codearea.NewWithState(codearea.Config{}, codearea.State{})
+	closed := false
+	w := New(Config{
+		Store:    st,
+		CodeArea: codeArea,
+		Close:    func() { closed = true },
+	})
+
+	tests := []struct {
+		name      string
+		events    []term.Event
+		wantMatch string
+	}{
+		{
+			name:      "initial match is the most recent command",
+			events:    nil,
+			wantMatch: "echo foobar",
+		},
+		{
+			name:      "typing narrows the match",
+			events:    []term.Event{key(ui.K('f')), key(ui.K('o')), key(ui.K('o'))},
+			wantMatch: "echo foobar",
+		},
+		{
+			name: "Ctrl-R steps to an older match",
+			events: []term.Event{
+				key(ui.K('f')), key(ui.K('o')), key(ui.K('o')),
+				key(ui.K('R', ui.Ctrl)),
+			},
+			wantMatch: "echo foo",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := New(Config{Store: st, CodeArea: codeArea, Close: func() {}}).(*widget)
+			for _, ev := range test.events {
+				w.Handle(ev)
+			}
+			if got := w.CopyState().Match; got != test.wantMatch {
+				t.Errorf("got match %q, want %q", got, test.wantMatch)
+			}
+		})
+	}
+
+	w.Handle(key(ui.K(ui.Escape)))
+	if !closed {
+		t.Errorf("Escape did not close the widget")
+	}
+}
+
+func lineText(line ui.Line) string {
+	s := ""
+	for _, cell := range line {
+		s += cell.Text
+	}
+	return s
+}
+
+func TestWidget_RenderHighlightsQueryInMatch(t *testing.T) {
+	st, cleanup := store.MustGetTempStore()
+	defer cleanup()
+	st.AddCmd("echo foobar")
+
+	codeArea := codearea.NewWithState(codearea.Config{}, codearea.State{})
+	w := New(Config{Store: st, CodeArea: codeArea, Close: func() {}}).(*widget)
+	w.Handle(key(ui.K('f')))
+	w.Handle(key(ui.K('o')))
+	w.Handle(key(ui.K('o')))
+
+	buf := w.Render(40, 10)
+	if len(buf.Lines) != 2 {
+		t.Fatalf("got %d lines, want 2 (mode line + match)", len(buf.Lines))
+	}
+	if got := lineText(buf.Lines[1]); got != "echo foobar" {
+		t.Errorf("got match line %q, want %q", got, "echo foobar")
+	}
+	// The query should split the match line into more than one cell run,
+	// since the "foo" substring is styled differently from its neighbors.
+	if len(buf.Lines[1]) < 2 {
+		t.Errorf("got %d cells on the match line, want the query highlighted as its own run", len(buf.Lines[1]))
+	}
+}
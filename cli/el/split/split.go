@@ -0,0 +1,261 @@
+// Package split implements a container widget that arranges child widgets
+// in horizontal or vertical splits with adjustable weights.
+package split
+
+import (
+	"github.com/elves/elvish/cli/el"
+	"github.com/elves/elvish/cli/term"
+	"github.com/elves/elvish/edit/ui"
+)
+
+// Orientation describes how a split's children are laid out.
+type Orientation int
+
+// Valid values of Orientation.
+const (
+	Horizontal Orientation = iota
+	Vertical
+)
+
+// Child is one pane of a split, together with the size hint used to
+// allocate it a share of the available rows or columns.
+type Child struct {
+	Widget el.Widget
+	// Fixed, if positive, gives the pane's size in rows (Vertical) or
+	// columns (Horizontal), regardless of the other panes.
+	Fixed int
+	// Weight determines the share of the remaining space given to the
+	// pane, relative to the other non-fixed panes. Panes with Weight 0
+	// default to a weight of 1.
+	Weight int
+}
+
+// Widget is a container that arranges its children in a split. It
+// implements the el.Widget interface.
+type Widget interface {
+	el.Widget
+	// Focus returns the index of the currently focused pane.
+	Focus() int
+	// SetFocus sets the index of the currently focused pane.
+	SetFocus(i int)
+}
+
+// Config keeps the configuration for Widget.
+type Config struct {
+	Orientation Orientation
+	Children    []Child
+}
+
+type widget struct {
+	Config
+	focus int
+	// awaitingPaneCmd is set after Ctrl-W, while waiting for the arrow key
+	// (switch focus) or +/- (adjust split ratio) that follows it.
+	awaitingPaneCmd bool
+}
+
+// New creates a new split container widget.
+func New(cfg Config) Widget {
+	return &widget{Config: cfg}
+}
+
+func (w *widget) Focus() int { return w.focus }
+
+func (w *widget) SetFocus(i int) {
+	if i >= 0 && i < len(w.Children) {
+		w.focus = i
+	}
+}
+
+// allocate divides total among the children according to their Fixed and
+// Weight fields.
+func allocate(children []Child, total int) []int {
+	sizes := make([]int, len(children))
+	remaining := total
+	totalWeight := 0
+	for i, c := range children {
+		if c.Fixed > 0 {
+			sizes[i] = c.Fixed
+			remaining -= c.Fixed
+		} else {
+			w := c.Weight
+			if w <= 0 {
+				w = 1
+			}
+			totalWeight += w
+		}
+	}
+	if remaining < 0 {
+		remaining = 0
+	}
+	given := 0
+	for i, c := range children {
+		if c.Fixed > 0 {
+			continue
+		}
+		w := c.Weight
+		if w <= 0 {
+			w = 1
+		}
+		size := remaining * w / totalWeight
+		sizes[i] = size
+		given += size
+	}
+	// Hand any leftover, rounding-induced slack to the last non-fixed pane so
+	// the panes exactly fill the available space.
+	if slack := remaining - given; slack > 0 {
+		for i := len(children) - 1; i >= 0; i-- {
+			if children[i].Fixed == 0 {
+				sizes[i] += slack
+				break
+			}
+		}
+	}
+	return sizes
+}
+
+func (w *widget) Render(width, height int) *ui.Buffer {
+	if len(w.Children) == 0 {
+		return &ui.Buffer{Width: width}
+	}
+	switch w.Orientation {
+	case Horizontal:
+		return w.renderHorizontal(width, height)
+	default:
+		return w.renderVertical(width, height)
+	}
+}
+
+func (w *widget) renderVertical(width, height int) *ui.Buffer {
+	sizes := allocate(w.Children, height)
+	buf := &ui.Buffer{Width: width}
+	for i, c := range w.Children {
+		if sizes[i] <= 0 {
+			continue
+		}
+		rowOffset := len(buf.Lines)
+		child := c.Widget.Render(width, sizes[i])
+		buf.Lines = append(buf.Lines, child.Lines...)
+		if i == w.focus {
+			translateDot(buf, child, rowOffset, 0)
+		}
+	}
+	return buf
+}
+
+func (w *widget) renderHorizontal(width, height int) *ui.Buffer {
+	sizes := allocate(w.Children, width)
+	childBufs := make([]*ui.Buffer, len(w.Children))
+	colOffsets := make([]int, len(w.Children))
+	col := 0
+	for i, c := range w.Children {
+		colOffsets[i] = col
+		col += sizes[i]
+		if sizes[i] <= 0 {
+			continue
+		}
+		childBufs[i] = c.Widget.Render(sizes[i], height)
+	}
+	buf := &ui.Buffer{Width: width}
+	for row := 0; row < height; row++ {
+		var line ui.Line
+		for i, cb := range childBufs {
+			if cb == nil {
+				continue
+			}
+			if row < len(cb.Lines) {
+				line = append(line, padLine(cb.Lines[row], sizes[i])...)
+			} else {
+				line = append(line, padLine(nil, sizes[i])...)
+			}
+		}
+		buf.Lines = append(buf.Lines, line)
+	}
+	if focused := childBufs[w.focus]; focused != nil {
+		translateDot(buf, focused, 0, colOffsets[w.focus])
+	}
+	return buf
+}
+
+// translateDot copies the cursor position that child recorded on its own
+// rendered buffer, if any, into buf at the given offset.
+func translateDot(buf *ui.Buffer, child *ui.Buffer, rowOffset, colOffset int) {
+	if child.Dot == (ui.Pos{}) {
+		return
+	}
+	buf.Dot = ui.Pos{Line: rowOffset + child.Dot.Line, Col: colOffset + child.Dot.Col}
+}
+
+func padLine(line ui.Line, width int) ui.Line {
+	w := 0
+	for _, cell := range line {
+		w += len(cell.Text)
+	}
+	if w >= width {
+		return line
+	}
+	return append(line, ui.Cell{Text: spaces(width - w)})
+}
+
+func spaces(n int) string {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = ' '
+	}
+	return string(b)
+}
+
+// Handle routes event to the focused pane, except for the Ctrl-W pane
+// command: Ctrl-W followed by an arrow key shifts focus to the adjacent
+// pane, and Ctrl-W followed by +/- grows or shrinks the focused pane's
+// weight.
+func (w *widget) Handle(event term.Event) bool {
+	if len(w.Children) == 0 {
+		return false
+	}
+	key, ok := event.(term.KeyEvent)
+	if !ok {
+		return w.Children[w.focus].Widget.Handle(event)
+	}
+	if w.awaitingPaneCmd {
+		w.awaitingPaneCmd = false
+		return w.handlePaneCmd(ui.Key(key))
+	}
+	if ui.Key(key) == ui.K('W', ui.Ctrl) {
+		w.awaitingPaneCmd = true
+		return true
+	}
+	return w.Children[w.focus].Widget.Handle(event)
+}
+
+func (w *widget) handlePaneCmd(key ui.Key) bool {
+	switch key {
+	case ui.K(ui.Up), ui.K(ui.Left):
+		w.SetFocus((w.focus - 1 + len(w.Children)) % len(w.Children))
+		return true
+	case ui.K(ui.Down), ui.K(ui.Right):
+		w.SetFocus((w.focus + 1) % len(w.Children))
+		return true
+	case ui.K('+'):
+		w.adjustWeight(1)
+		return true
+	case ui.K('-'):
+		w.adjustWeight(-1)
+		return true
+	default:
+		return false
+	}
+}
+
+// adjustWeight grows or shrinks the focused pane's weight by delta,
+// keeping it at least 1.
+func (w *widget) adjustWeight(delta int) {
+	c := &w.Children[w.focus]
+	if c.Weight <= 0 {
+		c.Weight = 1
+	}
+	c.Weight += delta
+	if c.Weight < 1 {
+		c.Weight = 1
+	}
+}
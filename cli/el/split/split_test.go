@@ -0,0 +1,92 @@
+package split
+
+import (
+	"testing"
+
+	"github.com/elves/elvish/cli/term"
+	"github.com/elves/elvish/edit/ui"
+)
+
+type fixedWidget struct{ line string }
+
+func (w fixedWidget) Render(width, height int) *ui.Buffer {
+	return &ui.Buffer{Width: width, Lines: []ui.Line{{ui.Cell{Text: w.line}}}}
+}
+
+func (w fixedWidget) Handle(event term.Event) bool { return false }
+
+func TestWidget_Vertical(t *testing.T) {
+	w := New(Config{
+		Orientation: Vertical,
+		Children: []Child{
+			{Widget: fixedWidget{"top"}, Fixed: 1},
+			{Widget: fixedWidget{"bottom"}, Fixed: 1},
+		},
+	})
+	buf := w.Render(10, 2)
+	if len(buf.Lines) != 2 {
+		t.Fatalf("got %d lines, want 2", len(buf.Lines))
+	}
+}
+
+func TestWidget_Horizontal(t *testing.T) {
+	w := New(Config{
+		Orientation: Horizontal,
+		Children: []Child{
+			{Widget: fixedWidget{"left"}, Fixed: 4},
+			{Widget: fixedWidget{"right"}, Fixed: 5},
+		},
+	})
+	buf := w.Render(10, 1)
+	if len(buf.Lines) != 1 {
+		t.Fatalf("got %d lines, want 1", len(buf.Lines))
+	}
+}
+
+func TestWidget_Empty(t *testing.T) {
+	w := New(Config{Orientation: Horizontal})
+	buf := w.Render(10, 5)
+	if len(buf.Lines) != 0 {
+		t.Errorf("got %d lines, want 0 for a split with no children", len(buf.Lines))
+	}
+	if w.Handle(term.KeyEvent(ui.K('x'))) {
+		t.Errorf("Handle on an empty split should return false")
+	}
+}
+
+func TestWidget_FocusSwitch(t *testing.T) {
+	var handled []int
+	w := New(Config{
+		Children: []Child{
+			{Widget: recordingWidget{0, &handled}, Weight: 1},
+			{Widget: recordingWidget{1, &handled}, Weight: 1},
+		},
+	})
+
+	ctrlW := term.KeyEvent(ui.K('W', ui.Ctrl))
+	down := term.KeyEvent(ui.K(ui.Down))
+	w.Handle(ctrlW)
+	w.Handle(down)
+	if w.(interface{ Focus() int }).Focus() != 1 {
+		t.Errorf("Ctrl-W Down did not move focus to pane 1")
+	}
+
+	w.Handle(term.KeyEvent(ui.K('x')))
+	if len(handled) != 1 || handled[0] != 1 {
+		t.Errorf("event was not routed to the focused pane: %v", handled)
+	}
+}
+
+type recordingWidget struct {
+	index   int
+	handled *[]int
+}
+
+func (w recordingWidget) Render(width, height int) *ui.Buffer {
+	return &ui.Buffer{Width: width}
+}
+
+func (w recordingWidget) Handle(event term.Event) bool {
+	*w.handled = append(*w.handled, w.index)
+	return true
+}
@@ -0,0 +1,84 @@
+package cli
+
+import (
+	"context"
+	"sync"
+
+	"github.com/elves/elvish/styled"
+)
+
+// highlightState tracks the generation and cancellation of the in-flight
+// background work behind the Highlighter's most recent Get call, and caches
+// the latest late update that is not known to be stale.
+type highlightState struct {
+	highlighter Highlighter
+
+	mu       sync.Mutex
+	lastCode string
+	gen      int
+	cancel   context.CancelFunc
+	content  styled.Text
+	errors   []error
+}
+
+// get has the same signature as codearea.Config.Highlighter, which is
+// called synchronously on every render. If code is unchanged since the
+// last call, it returns the cached content as is -- in particular, any
+// update that watch has since copied in from a LateUpdates delivery --
+// instead of cancelling that work and starting over. Only when code has
+// actually changed does it cancel the previous background work and call
+// Get again under a fresh, cancellable, generation-tagged context.
+func (s *highlightState) get(code string) (styled.Text, []error) {
+	s.mu.Lock()
+	if code == s.lastCode {
+		content, errs := s.content, s.errors
+		s.mu.Unlock()
+		return content, errs
+	}
+	s.lastCode = code
+	if s.cancel != nil {
+		s.cancel()
+	}
+	s.gen++
+	gen := s.gen
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	s.mu.Unlock()
+
+	content, errs := s.highlighter.Get(WithGen(ctx, gen), code)
+
+	s.mu.Lock()
+	if gen == s.gen {
+		s.content, s.errors = content, errs
+	}
+	s.mu.Unlock()
+
+	return content, errs
+}
+
+// watch starts a goroutine that copies updates from h's LateUpdates channel
+// into s.content and s.errors, dropping any update whose Gen is not the
+// generation most recently passed to get.
+func (s *highlightState) watch(h Highlighter) {
+	ch := h.LateUpdates()
+	if ch == nil {
+		return
+	}
+	go func() {
+		for update := range ch {
+			s.mu.Lock()
+			if update.Gen == s.gen {
+				s.content, s.errors = update.Content, update.Errors
+			}
+			s.mu.Unlock()
+		}
+	}()
+}
+
+// latest returns the most recent content and errors known not to be stale,
+// whether from the initial synchronous Get or a later LateUpdates delivery.
+func (s *highlightState) latest() (styled.Text, []error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.content, s.errors
+}
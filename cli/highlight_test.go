@@ -0,0 +1,75 @@
+package cli
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/elves/elvish/styled"
+)
+
+func TestHighlightState_CancelledGenerationDoesNotClobberFresherOne(t *testing.T) {
+	ch := make(chan HighlightUpdate)
+	hl := testHighlighter{
+		get: func(ctx context.Context, code string) (styled.Text, []error) {
+			return styled.Plain(code), nil
+		},
+		lateUpdates: ch,
+	}
+
+	s := highlightState{highlighter: hl}
+	s.watch(hl)
+
+	s.get("gen 1")
+	s.get("gen 2") // supersedes generation 1
+
+	ch <- HighlightUpdate{Gen: 2, Content: styled.Plain("fresh")}
+	// A late result from the now-cancelled generation 1 must be dropped
+	// rather than clobbering the fresher content.
+	ch <- HighlightUpdate{Gen: 1, Content: styled.Plain("stale")}
+
+	// The channel is unbuffered, so sending a final update for the current
+	// generation only completes once the watch goroutine has received it --
+	// which, since updates are processed one at a time in order, can only
+	// happen after the stale update above has already been handled. Sending
+	// it a second time likewise ensures the first copy has been stored.
+	sentinel := styled.Plain("sentinel")
+	ch <- HighlightUpdate{Gen: 2, Content: sentinel}
+	ch <- HighlightUpdate{Gen: 2, Content: sentinel}
+
+	if got, _ := s.latest(); !reflect.DeepEqual(got, sentinel) {
+		t.Errorf("got content %v after stale update, want unaffected %v", got, sentinel)
+	}
+}
+
+func TestHighlightState_GetSurfacesLateUpdateForUnchangedCode(t *testing.T) {
+	ch := make(chan HighlightUpdate)
+	calls := 0
+	hl := testHighlighter{
+		get: func(ctx context.Context, code string) (styled.Text, []error) {
+			calls++
+			return styled.Plain("initial"), nil
+		},
+		lateUpdates: ch,
+	}
+
+	s := highlightState{highlighter: hl}
+	s.watch(hl)
+
+	s.get("code")
+	fresh := styled.Plain("fresh")
+	ch <- HighlightUpdate{Gen: 1, Content: fresh}
+	// The channel is unbuffered, so a second send only completes once the
+	// watch goroutine has stored the first one.
+	ch <- HighlightUpdate{Gen: 1, Content: fresh}
+
+	// A render with the same code must surface the late update instead of
+	// discarding it by restarting the Get call from scratch.
+	got, _ := s.get("code")
+	if !reflect.DeepEqual(got, fresh) {
+		t.Errorf("got content %v on unchanged code, want late update %v", got, fresh)
+	}
+	if calls != 1 {
+		t.Errorf("got %d calls to Get, want 1 for unchanged code", calls)
+	}
+}
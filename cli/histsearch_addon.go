@@ -0,0 +1,35 @@
+package cli
+
+import (
+	"github.com/elves/elvish/cli/el/histsearch"
+	"github.com/elves/elvish/cli/term"
+	"github.com/elves/elvish/edit/ui"
+)
+
+// The default keybinding for starting a history search.
+var histSearchKey = ui.K('R', ui.Ctrl)
+
+// tryStartHistSearch pushes the history search addon when Ctrl-R is
+// pressed while no addon is currently active and a HistoryStore has been
+// configured. It returns whether the event was consumed.
+func (app *App) tryStartHistSearch(event term.Event) bool {
+	if app.addonActive() {
+		return false
+	}
+	key, ok := event.(term.KeyEvent)
+	if !ok || ui.Key(key) != histSearchKey || app.cfg.HistoryStore == nil {
+		return false
+	}
+	app.PushAddon(histsearch.New(histsearch.Config{
+		Store:    app.cfg.HistoryStore,
+		CodeArea: app.CodeArea,
+		Close:    app.PopAddon,
+	}))
+	return true
+}
+
+func (app *App) addonActive() bool {
+	app.addonMutex.RLock()
+	defer app.addonMutex.RUnlock()
+	return app.addons.Active() != app.addons.Base
+}
@@ -0,0 +1,60 @@
+package cli
+
+import (
+	"context"
+	"sync"
+
+	"github.com/elves/elvish/styled"
+)
+
+// promptState tracks the generation and cancellation of the in-flight
+// Trigger call for a Prompt, and caches the latest content that is not
+// known to be stale.
+type promptState struct {
+	mu      sync.RWMutex
+	gen     int
+	cancel  context.CancelFunc
+	content styled.Text
+}
+
+// refresh cancels any Trigger still in flight for p, then starts a new one
+// under a fresh, cancellable, generation-tagged context.
+func (s *promptState) refresh(p Prompt, force bool) {
+	s.mu.Lock()
+	if s.cancel != nil {
+		s.cancel()
+	}
+	s.gen++
+	gen := s.gen
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	s.mu.Unlock()
+
+	p.Trigger(WithGen(ctx, gen), force)
+}
+
+// watch starts a goroutine that copies updates from p's LateUpdates
+// channel into s.content, dropping any update whose Gen is not the
+// generation most recently passed to refresh.
+func (s *promptState) watch(p Prompt) {
+	ch := p.LateUpdates()
+	if ch == nil {
+		return
+	}
+	go func() {
+		for update := range ch {
+			s.mu.Lock()
+			if update.Gen == s.gen {
+				s.content = update.Content
+			}
+			s.mu.Unlock()
+		}
+	}()
+}
+
+// get returns the latest content known not to be stale.
+func (s *promptState) get() styled.Text {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.content
+}
@@ -0,0 +1,37 @@
+package cli
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/elves/elvish/styled"
+)
+
+func TestPromptState_CancelledGenerationDoesNotClobberFresherOne(t *testing.T) {
+	ch := make(chan PromptUpdate)
+	prompt := testPrompt{lateUpdates: ch}
+
+	var s promptState
+	s.watch(prompt)
+
+	s.refresh(prompt, false) // generation 1
+	s.refresh(prompt, false) // generation 2, supersedes generation 1
+
+	ch <- PromptUpdate{Gen: 2, Content: styled.Plain("fresh")}
+	// A late result from the now-cancelled generation 1 must be dropped
+	// rather than clobbering the fresher content.
+	ch <- PromptUpdate{Gen: 1, Content: styled.Plain("stale")}
+
+	// The channel is unbuffered, so sending a final update for the current
+	// generation only completes once the watch goroutine has received it --
+	// which, since updates are processed one at a time in order, can only
+	// happen after the stale update above has already been handled. Sending
+	// it a second time likewise ensures the first copy has been stored.
+	sentinel := styled.Plain("sentinel")
+	ch <- PromptUpdate{Gen: 2, Content: sentinel}
+	ch <- PromptUpdate{Gen: 2, Content: sentinel}
+
+	if got := s.get(); !reflect.DeepEqual(got, sentinel) {
+		t.Errorf("got content %v after stale update, want unaffected %v", got, sentinel)
+	}
+}
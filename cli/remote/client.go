@@ -0,0 +1,139 @@
+package remote
+
+import (
+	"encoding/gob"
+	"errors"
+	"io"
+
+	"github.com/elves/elvish/cli"
+	"github.com/elves/elvish/cli/term"
+	"github.com/elves/elvish/edit/ui"
+)
+
+// Client is the attaching side of a remote session: it sends terminal
+// events and resize notifications to a Server, and receives back rendered
+// frames.
+type Client struct {
+	enc *gob.Encoder
+	dec *gob.Decoder
+
+	frames chan *ui.Buffer
+	bells  chan struct{}
+	exit   chan ExitMsg
+}
+
+// NewClient wraps rw, an already-established connection to a Server, and
+// starts relaying ServerMessages off of it. Callers read frames, bells and
+// the exit notification from the returned Client's channels.
+func NewClient(rw io.ReadWriter) *Client {
+	c := &Client{
+		enc:    gob.NewEncoder(rw),
+		dec:    gob.NewDecoder(rw),
+		frames: make(chan *ui.Buffer),
+		bells:  make(chan struct{}),
+		exit:   make(chan ExitMsg, 1),
+	}
+	go c.recvLoop()
+	return c
+}
+
+func (c *Client) recvLoop() {
+	for {
+		var msg ServerMessage
+		if err := c.dec.Decode(&msg); err != nil {
+			c.exit <- ExitMsg{Reason: err.Error()}
+			return
+		}
+		if msg.Frame != nil {
+			c.frames <- msg.Frame
+		}
+		if msg.Bell {
+			c.bells <- struct{}{}
+		}
+		if msg.Exit != nil {
+			c.exit <- *msg.Exit
+			return
+		}
+	}
+}
+
+// Frames returns the channel on which rendered frames are delivered.
+func (c *Client) Frames() <-chan *ui.Buffer { return c.frames }
+
+// Bells returns the channel on which bell notifications are delivered.
+func (c *Client) Bells() <-chan struct{} { return c.bells }
+
+// Exit returns the channel on which the session's end is reported, either
+// because the server disconnected this client or the connection broke.
+func (c *Client) Exit() <-chan ExitMsg { return c.exit }
+
+// SendEvent forwards a terminal event to the Server.
+func (c *Client) SendEvent(event term.Event) error {
+	return c.enc.Encode(ClientMessage{Event: event})
+}
+
+// SendResize notifies the Server of a terminal size change.
+func (c *Client) SendResize(width, height int) error {
+	return c.enc.Encode(ClientMessage{Resize: &ResizeMsg{Width: width, Height: height}})
+}
+
+// TakeSolo requests a graceful takeover, detaching every other client
+// attached to the session.
+func (c *Client) TakeSolo() error {
+	return c.enc.Encode(ClientMessage{Solo: true})
+}
+
+// Observe attaches in read-only mode: the client still receives frames,
+// but its events and resizes are ignored by the Server.
+func (c *Client) Observe() error {
+	return c.enc.Encode(ClientMessage{Observer: true})
+}
+
+// Detach tells the Server to drop this client without affecting others.
+func (c *Client) Detach() error {
+	return c.enc.Encode(ClientMessage{Detach: true})
+}
+
+// RunAttached bridges tty, the local terminal, and c, a connection to a
+// remote session: events read from tty are forwarded to the Server, and
+// frames and bells received back are drawn to tty. This is what lets an
+// existing cli.App be driven by a remote session transparently -- the App
+// only ever talks to tty (its own cli.TTY), which happens to be relaying
+// everything over c rather than to a terminal device of its own.
+//
+// RunAttached blocks until the session ends, returning the reason reported
+// by the Server, or the first error encountered reading from tty or
+// writing to c.
+func RunAttached(tty cli.TTY, c *Client) error {
+	errCh := make(chan error, 1)
+	go func() {
+		for {
+			event, err := tty.Next()
+			if err != nil {
+				errCh <- err
+				return
+			}
+			if err := c.SendEvent(event); err != nil {
+				errCh <- err
+				return
+			}
+		}
+	}()
+	for {
+		select {
+		case buf := <-c.Frames():
+			if err := tty.UpdateBuffer(buf); err != nil {
+				return err
+			}
+		case <-c.Bells():
+			// No bell support in cli.TTY; dropped.
+		case exit := <-c.Exit():
+			if exit.Reason == "" {
+				return nil
+			}
+			return errors.New(exit.Reason)
+		case err := <-errCh:
+			return err
+		}
+	}
+}
@@ -0,0 +1,58 @@
+// Package remote implements a server that hosts a cli.App and exposes it
+// over a Unix socket, so that thin clients can attach, stream terminal
+// events to the server, and receive back rendered frames. Multiple clients
+// may attach to the same session at once.
+package remote
+
+import (
+	"encoding/gob"
+
+	"github.com/elves/elvish/cli/term"
+	"github.com/elves/elvish/edit/ui"
+)
+
+func init() {
+	gob.Register(term.KeyEvent{})
+	gob.Register(term.PasteSetting(false))
+}
+
+// ClientMessage is sent from a Client to the Server.
+type ClientMessage struct {
+	// Resize, if non-nil, reports that the client's terminal has been
+	// resized.
+	Resize *ResizeMsg
+	// Event, if non-nil, is a terminal event to feed to the hosted App.
+	Event term.Event
+	// Detach requests that the client be detached from the session,
+	// without affecting other attached clients.
+	Detach bool
+	// Solo requests a graceful takeover: every other attached client is
+	// sent an Exit message and disconnected, leaving this client as the
+	// sole (non-observer) client of the session.
+	Solo bool
+	// Observer attaches the client in read-only mode: it receives frames
+	// but its Event and Resize messages are ignored.
+	Observer bool
+}
+
+// ResizeMsg reports a terminal size change.
+type ResizeMsg struct {
+	Width, Height int
+}
+
+// ServerMessage is sent from the Server to a Client.
+type ServerMessage struct {
+	// Frame, if non-nil, is the next rendered frame to display.
+	Frame *ui.Buffer
+	// Bell requests that the client ring the terminal bell.
+	Bell bool
+	// Exit, if non-nil, tells the client that it has been disconnected
+	// from the session and should shut down.
+	Exit *ExitMsg
+}
+
+// ExitMsg carries the reason a client was disconnected.
+type ExitMsg struct {
+	Code   int
+	Reason string
+}
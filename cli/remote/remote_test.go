@@ -0,0 +1,111 @@
+package remote
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/elves/elvish/cli"
+	"github.com/elves/elvish/cli/term"
+	"github.com/elves/elvish/edit/ui"
+)
+
+func dialServer(t *testing.T, s *Server) *Client {
+	t.Helper()
+	serverSide, clientSide := net.Pipe()
+	go s.Serve(serverSide)
+	return NewClient(clientSide)
+}
+
+func recvFrame(t *testing.T, c *Client) *ui.Buffer {
+	t.Helper()
+	select {
+	case buf := <-c.Frames():
+		return buf
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for frame")
+		return nil
+	}
+}
+
+func TestServer_BroadcastsFramesToAllClients(t *testing.T) {
+	app := cli.NewApp(cli.AppSpec{})
+	s := NewServer(app, 80, 24)
+
+	c1 := dialServer(t, s)
+	c2 := dialServer(t, s)
+
+	c1.SendEvent(term.KeyEvent(ui.K('a')))
+	recvFrame(t, c1)
+	recvFrame(t, c2)
+}
+
+func TestServer_Solo(t *testing.T) {
+	app := cli.NewApp(cli.AppSpec{})
+	s := NewServer(app, 80, 24)
+
+	c1 := dialServer(t, s)
+	c2 := dialServer(t, s)
+
+	c1.TakeSolo()
+	recvFrame(t, c1)
+
+	select {
+	case exit := <-c2.Exit():
+		if exit.Reason == "" {
+			t.Errorf("expected a non-empty reason for eviction")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("c2 was not evicted after c1 took solo control")
+	}
+}
+
+func TestServer_SoloClosesEvictedConnection(t *testing.T) {
+	app := cli.NewApp(cli.AppSpec{})
+	s := NewServer(app, 80, 24)
+
+	serverSide, clientSide := net.Pipe()
+	done := make(chan struct{})
+	go func() {
+		s.Serve(serverSide)
+		close(done)
+	}()
+	c1 := NewClient(clientSide)
+
+	c2 := dialServer(t, s)
+	c2.TakeSolo()
+	recvFrame(t, c2)
+
+	select {
+	case <-c1.Exit():
+	case <-time.After(time.Second):
+		t.Fatal("c1 was not evicted after c2 took solo control")
+	}
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Serve did not return once its connection was closed on eviction")
+	}
+}
+
+func TestServer_ObserverStaysReadOnly(t *testing.T) {
+	app := cli.NewApp(cli.AppSpec{})
+	s := NewServer(app, 80, 24)
+
+	c := dialServer(t, s)
+	c.Observe()
+	recvFrame(t, c)
+
+	// A later message that doesn't itself set Observer (here a resize)
+	// must not clear the sticky observer flag and be allowed through.
+	c.SendResize(100, 30)
+	recvFrame(t, c)
+
+	s.mu.Lock()
+	width := s.width
+	s.mu.Unlock()
+	if width != 80 {
+		t.Errorf("got width %d after resize from an observer, want unchanged 80", width)
+	}
+}
@@ -0,0 +1,148 @@
+package remote
+
+import (
+	"encoding/gob"
+	"io"
+	"sync"
+
+	"github.com/elves/elvish/cli"
+)
+
+// Server hosts a cli.App and lets one or more Clients attach to it over a
+// connection such as a Unix socket.
+type Server struct {
+	app *cli.App
+
+	mu       sync.Mutex
+	conns    map[*serverConn]struct{}
+	soloConn *serverConn
+	width    int
+	height   int
+}
+
+// NewServer creates a Server hosting app, with an initial size of width by
+// height (used until the first client reports a different size).
+func NewServer(app *cli.App, width, height int) *Server {
+	return &Server{app: app, conns: map[*serverConn]struct{}{}, width: width, height: height}
+}
+
+type serverConn struct {
+	enc    *gob.Encoder
+	closer io.Closer
+	// observer is sticky: once a client sends Observer, it stays a
+	// read-only observer for the rest of the connection, regardless of
+	// what later messages (which leave ClientMessage.Observer at its zero
+	// value) might otherwise imply.
+	observer bool
+}
+
+// Serve handles a single client connection until it detaches or rw is
+// closed. Callers typically call Serve in its own goroutine per accepted
+// connection. rw is closed before Serve returns.
+func (s *Server) Serve(rw io.ReadWriteCloser) error {
+	dec := gob.NewDecoder(rw)
+	conn := &serverConn{enc: gob.NewEncoder(rw), closer: rw}
+
+	s.addConn(conn)
+	defer func() {
+		s.removeConn(conn)
+		rw.Close()
+	}()
+
+	for {
+		var msg ClientMessage
+		if err := dec.Decode(&msg); err != nil {
+			return err
+		}
+		if msg.Detach {
+			return nil
+		}
+		if msg.Solo {
+			s.makeSolo(conn)
+		}
+		if msg.Observer {
+			conn.observer = true
+		}
+		if !conn.observer && s.acceptsInput(conn) {
+			if msg.Resize != nil {
+				s.setSize(msg.Resize.Width, msg.Resize.Height)
+			}
+			if msg.Event != nil {
+				s.app.Handle(msg.Event)
+			}
+		}
+		s.broadcastFrame()
+	}
+}
+
+// acceptsInput reports whether conn's input should be applied to the App:
+// true unless another client has taken solo control.
+func (s *Server) acceptsInput(conn *serverConn) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.soloConn == nil || s.soloConn == conn
+}
+
+func (s *Server) setSize(width, height int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.width, s.height = width, height
+}
+
+func (s *Server) addConn(conn *serverConn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.conns[conn] = struct{}{}
+}
+
+func (s *Server) removeConn(conn *serverConn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.conns, conn)
+	if s.soloConn == conn {
+		s.soloConn = nil
+	}
+}
+
+// makeSolo disconnects every other attached client, leaving conn as the
+// only one able to drive the session. Evicted connections are removed from
+// the broadcast set immediately, so they are not sent any further frames,
+// and their underlying connection is closed so their blocked Serve call
+// returns instead of leaking.
+func (s *Server) makeSolo(conn *serverConn) {
+	s.mu.Lock()
+	s.soloConn = conn
+	var evicted []*serverConn
+	for c := range s.conns {
+		if c != conn {
+			evicted = append(evicted, c)
+			delete(s.conns, c)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, c := range evicted {
+		c.enc.Encode(ServerMessage{
+			Exit: &ExitMsg{Reason: "took over by another client"},
+		})
+		c.closer.Close()
+	}
+}
+
+// broadcastFrame renders the App and sends the resulting frame to every
+// attached client.
+func (s *Server) broadcastFrame() {
+	s.mu.Lock()
+	width, height := s.width, s.height
+	conns := make([]*serverConn, 0, len(s.conns))
+	for c := range s.conns {
+		conns = append(conns, c)
+	}
+	s.mu.Unlock()
+
+	buf := s.app.Render(width, height)
+	msg := ServerMessage{Frame: buf}
+	for _, c := range conns {
+		c.enc.Encode(msg)
+	}
+}
@@ -0,0 +1,7 @@
+package cli
+
+// State keeps the mutable state of an App that is not owned by any
+// particular widget.
+type State struct {
+	Notes []string
+}
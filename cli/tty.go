@@ -0,0 +1,15 @@
+package cli
+
+import (
+	"github.com/elves/elvish/cli/term"
+	"github.com/elves/elvish/edit/ui"
+)
+
+// TTY represents the terminal device an App reads input events from and
+// renders to.
+type TTY interface {
+	// Next blocks until the next terminal event is available.
+	Next() (term.Event, error)
+	// UpdateBuffer renders buf to the terminal.
+	UpdateBuffer(buf *ui.Buffer) error
+}